@@ -6,7 +6,6 @@ import (
 	"io"
 	"io/ioutil"
 	"log"
-	"net/http"
 	"runtime"
 	"strconv"
 	"sync"
@@ -25,10 +24,10 @@ import (
 	"github.com/containerd/containerd/content"
 	"github.com/containerd/containerd/errdefs"
 	"github.com/containerd/containerd/images"
+	"github.com/containerd/containerd/platforms"
 	"github.com/containerd/containerd/plugin"
 	"github.com/containerd/containerd/reference"
 	"github.com/containerd/containerd/remotes"
-	"github.com/containerd/containerd/remotes/docker"
 	"github.com/containerd/containerd/remotes/docker/schema1"
 	contentservice "github.com/containerd/containerd/services/content"
 	"github.com/containerd/containerd/services/diff"
@@ -301,14 +300,30 @@ type RemoteContext struct {
 	// manifests. If this option is false then any image which resolves
 	// to schema 1 will return an error since schema 1 is not supported.
 	ConvertSchema1 bool
+
+	// Hosts resolves a registry host name to an ordered list of candidate
+	// RegistryHosts, used instead of Resolver to build a mirror-aware
+	// resolver. Ignored if Resolver is set explicitly.
+	Hosts HostsFunc
+
+	// Platforms is the set of platforms to pull and unpack when the
+	// resolved descriptor is an index. Defaults to the host's own
+	// runtime.GOOS/GOARCH when neither this nor AllPlatforms is set.
+	Platforms []ocispec.Platform
+
+	// AllPlatforms disables platform filtering, pulling every manifest
+	// referenced by a resolved index.
+	AllPlatforms bool
+
+	// Progress, if set, receives a ProgressUpdate per descriptor as Pull
+	// or Push transfers it. The caller owns the channel's buffering and
+	// should drain it until the call returns; it is closed when dispatch
+	// finishes.
+	Progress chan<- ProgressUpdate
 }
 
 func defaultRemoteContext() *RemoteContext {
-	return &RemoteContext{
-		Resolver: docker.NewResolver(docker.ResolverOptions{
-			Client: http.DefaultClient,
-		}),
-	}
+	return &RemoteContext{}
 }
 
 // WithPullUnpack is used to unpack an image after pull. This
@@ -351,6 +366,38 @@ func WithImageHandler(h images.Handler) RemoteOpts {
 	}
 }
 
+// WithPlatform requests that Pull restrict a resolved manifest-list/index
+// to the given platform, specified as "os/arch[/variant]" (e.g.
+// "linux/arm64/v8"). May be given multiple times to pull more than one
+// platform. Ignored for refs that resolve directly to a single-platform
+// manifest.
+func WithPlatform(platform string) RemoteOpts {
+	return func(client *Client, c *RemoteContext) error {
+		p, err := platforms.Parse(platform)
+		if err != nil {
+			return errors.Wrapf(err, "invalid platform %s", platform)
+		}
+		c.Platforms = append(c.Platforms, p)
+		return nil
+	}
+}
+
+// WithAllPlatforms requests that Pull fetch every platform referenced by a
+// resolved manifest-list/index, instead of just the host's.
+func WithAllPlatforms(client *Client, c *RemoteContext) error {
+	c.AllPlatforms = true
+	return nil
+}
+
+// WithProgress streams ProgressUpdates for a Pull or Push to ch. ch is
+// closed once the transfer dispatch finishes.
+func WithProgress(ch chan<- ProgressUpdate) RemoteOpts {
+	return func(client *Client, c *RemoteContext) error {
+		c.Progress = ch
+		return nil
+	}
+}
+
 // Pull downloads the provided content into containerd's content store
 func (c *Client) Pull(ctx context.Context, ref string, opts ...RemoteOpts) (Image, error) {
 	pullCtx := defaultRemoteContext()
@@ -361,18 +408,31 @@ func (c *Client) Pull(ctx context.Context, ref string, opts ...RemoteOpts) (Imag
 	}
 	store := c.ContentStore()
 
-	name, desc, err := pullCtx.Resolver.Resolve(ctx, ref)
+	resolver, err := resolverForRef(pullCtx, ref)
 	if err != nil {
 		return nil, err
 	}
-	fetcher, err := pullCtx.Resolver.Fetcher(ctx, name)
+
+	name, desc, err := resolver.Resolve(ctx, ref)
 	if err != nil {
 		return nil, err
 	}
+	fetcher, err := resolver.Fetcher(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	var tracker *progressTracker
+	if pullCtx.Progress != nil {
+		tracker = newProgressTracker(ctx, ref, store, pullCtx.Progress)
+		defer tracker.Close()
+		fetcher = trackFetcher(fetcher, tracker)
+	}
 
 	var (
 		schema1Converter *schema1.Converter
 		handler          images.Handler
+		matched          []ocispec.Descriptor
 	)
 	if desc.MediaType == images.MediaTypeDockerSchema1Manifest && pullCtx.ConvertSchema1 {
 		schema1Converter = schema1.NewConverter(store, fetcher)
@@ -380,7 +440,7 @@ func (c *Client) Pull(ctx context.Context, ref string, opts ...RemoteOpts) (Imag
 	} else {
 		handler = images.Handlers(append(pullCtx.BaseHandlers,
 			remotes.FetchHandler(store, fetcher),
-			images.ChildrenHandler(store))...,
+			platformFilterHandler(pullCtx, store, &matched))...,
 		)
 	}
 
@@ -420,8 +480,26 @@ func (c *Client) Pull(ctx context.Context, ref string, opts ...RemoteOpts) (Imag
 		i:      imgrec,
 	}
 	if pullCtx.Unpack {
-		if err := img.Unpack(ctx, pullCtx.Snapshotter); err != nil {
-			return nil, err
+		if len(matched) == 0 {
+			// desc resolved directly to a single-platform manifest.
+			if err := img.Unpack(ctx, pullCtx.Snapshotter); err != nil {
+				return nil, err
+			}
+		} else {
+			// desc was an index; unpack once per matched platform manifest,
+			// each keying its own snapshot chain ID.
+			for _, m := range matched {
+				platformImg := &image{
+					client: c,
+					i: images.Image{
+						Name:   name,
+						Target: m,
+					},
+				}
+				if err := platformImg.Unpack(ctx, pullCtx.Snapshotter); err != nil {
+					return nil, err
+				}
+			}
 		}
 	}
 	return img, nil
@@ -436,11 +514,22 @@ func (c *Client) Push(ctx context.Context, ref string, desc ocispec.Descriptor,
 		}
 	}
 
-	pusher, err := pushCtx.Resolver.Pusher(ctx, ref)
+	resolver, err := resolverForRef(pushCtx, ref)
 	if err != nil {
 		return err
 	}
 
+	pusher, err := resolver.Pusher(ctx, ref)
+	if err != nil {
+		return err
+	}
+
+	if pushCtx.Progress != nil {
+		tracker := newProgressTracker(ctx, ref, c.ContentStore(), pushCtx.Progress)
+		defer tracker.Close()
+		pusher = trackPusher(pusher, tracker)
+	}
+
 	var m sync.Mutex
 	manifestStack := []ocispec.Descriptor{}
 
@@ -576,12 +665,15 @@ func (c *Client) Version(ctx context.Context) (Version, error) {
 type imageFormat string
 
 const (
-	ociImageFormat imageFormat = "oci"
+	ociImageFormat    imageFormat = "oci"
+	dockerImageFormat imageFormat = "docker"
 )
 
 type importOpts struct {
-	format    imageFormat
-	refObject string
+	format              imageFormat
+	refObject           string
+	progress            chan<- ProgressUpdate
+	translateMediaTypes bool
 }
 
 // ImportOpt allows the caller to specify import specific options
@@ -598,6 +690,41 @@ func WithOCIImportFormat() ImportOpt {
 	}
 }
 
+// WithDockerImportFormat sets the import format to the Docker `docker save`
+// tar layout (top-level manifest.json plus per-layer <hash>/layer.tar).
+func WithDockerImportFormat() ImportOpt {
+	return func(c *importOpts) error {
+		if c.format != "" {
+			return errors.New("format already set")
+		}
+		c.format = dockerImageFormat
+		return nil
+	}
+}
+
+// WithImportProgress streams ProgressUpdates for a WithDockerImportFormat
+// Import to ch. ch is closed once that import finishes. An OCI-format
+// import sends no updates and does not close ch.
+func WithImportProgress(ch chan<- ProgressUpdate) ImportOpt {
+	return func(c *importOpts) error {
+		c.progress = ch
+		return nil
+	}
+}
+
+// WithImportMediaTypeTranslation ingests the blobs of a Docker `docker save`
+// tar layout under their OCI media types (e.g.
+// application/vnd.docker.image.rootfs.diff.tar ->
+// application/vnd.oci.image.layer.v1.tar for layers) instead of the Docker
+// schema2 types used by default.
+// It has no effect on WithOCIImportFormat imports.
+func WithImportMediaTypeTranslation() ImportOpt {
+	return func(c *importOpts) error {
+		c.translateMediaTypes = true
+		return nil
+	}
+}
+
 // WithRefObject specifies the ref object to import.
 // If refObject is empty, it is copied from the ref argument of Import().
 func WithRefObject(refObject string) ImportOpt {
@@ -641,13 +768,17 @@ func (c *Client) Import(ctx context.Context, ref string, reader io.Reader, opts
 	switch iopts.format {
 	case ociImageFormat:
 		return c.importFromOCITar(ctx, ref, reader, iopts)
+	case dockerImageFormat:
+		return c.importFromDockerTar(ctx, ref, reader, iopts)
 	default:
 		return nil, errors.Errorf("unsupported format: %s", iopts.format)
 	}
 }
 
 type exportOpts struct {
-	format imageFormat
+	format              imageFormat
+	translateMediaTypes bool
+	progress            chan<- ProgressUpdate
 }
 
 // ExportOpt allows callers to set export options
@@ -664,9 +795,43 @@ func WithOCIExportFormat() ExportOpt {
 	}
 }
 
-// TODO: add WithMediaTypeTranslation that transforms media types according to the format.
-// e.g. application/vnd.docker.image.rootfs.diff.tar.gzip
-//      -> application/vnd.oci.image.layer.v1.tar+gzip
+// WithDockerExportFormat sets the export target to the Docker `docker save`
+// tar layout (top-level manifest.json plus per-layer <hash>/layer.tar).
+func WithDockerExportFormat() ExportOpt {
+	return func(c *exportOpts) error {
+		if c.format != "" {
+			return errors.New("format already set")
+		}
+		c.format = dockerImageFormat
+		return nil
+	}
+}
+
+// WithMediaTypeTranslation translates each layer's media type from its OCI
+// variant to the Docker schema2 equivalent (e.g.
+// application/vnd.oci.image.layer.v1.tar+gzip ->
+// application/vnd.docker.image.rootfs.diff.tar.gzip) before the exporter
+// decides whether the layer needs gunzipping into the plain tar entry that
+// `docker load` expects. Both gzip variants are gunzipped alike and the
+// archive records no media types, so the exported tar is the same with or
+// without this option. See WithImportMediaTypeTranslation for the
+// import-side equivalent.
+func WithMediaTypeTranslation() ExportOpt {
+	return func(c *exportOpts) error {
+		c.translateMediaTypes = true
+		return nil
+	}
+}
+
+// WithExportProgress streams ProgressUpdates for a WithDockerExportFormat
+// Export to ch. ch is closed once that export finishes. An OCI-format
+// export sends no updates and does not close ch.
+func WithExportProgress(ch chan<- ProgressUpdate) ExportOpt {
+	return func(c *exportOpts) error {
+		c.progress = ch
+		return nil
+	}
+}
 
 // Export exports an image to a Tar stream.
 // OCI format is used by default.
@@ -688,6 +853,10 @@ func (c *Client) Export(ctx context.Context, desc ocispec.Descriptor, opts ...Ex
 		go func() {
 			pw.CloseWithError(c.exportToOCITar(ctx, desc, pw, eopts))
 		}()
+	case dockerImageFormat:
+		go func() {
+			pw.CloseWithError(c.exportToDockerTar(ctx, desc, pw, eopts))
+		}()
 	default:
 		return nil, errors.Errorf("unsupported format: %s", eopts.format)
 	}
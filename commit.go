@@ -0,0 +1,205 @@
+package containerd
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/containerd/containerd/content"
+	"github.com/containerd/containerd/diff"
+	"github.com/containerd/containerd/errdefs"
+	"github.com/containerd/containerd/images"
+	"github.com/containerd/containerd/mount"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+)
+
+// CommitOpts allows the caller to configure the image produced by Commit.
+type CommitOpts func(*commitOpts) error
+
+type commitOpts struct {
+	author  string
+	message string
+	labels  map[string]string
+	config  func(*ocispec.ImageConfig)
+}
+
+// WithCommitAuthor sets the author recorded in the new image's history.
+func WithCommitAuthor(author string) CommitOpts {
+	return func(c *commitOpts) error {
+		c.author = author
+		return nil
+	}
+}
+
+// WithCommitMessage sets the commit message recorded in the new image's history.
+func WithCommitMessage(message string) CommitOpts {
+	return func(c *commitOpts) error {
+		c.message = message
+		return nil
+	}
+}
+
+// WithCommitLabels adds the provided labels to the new image.
+func WithCommitLabels(labels map[string]string) CommitOpts {
+	return func(c *commitOpts) error {
+		c.labels = labels
+		return nil
+	}
+}
+
+// WithCommitChanges applies fn to the parent image's config before it is
+// written as part of the new image, allowing callers to override entrypoint,
+// env, exposed ports, and other config fields, similar to `docker commit --change`.
+func WithCommitChanges(fn func(*ocispec.ImageConfig)) CommitOpts {
+	return func(c *commitOpts) error {
+		c.config = fn
+		return nil
+	}
+}
+
+// Commit snapshots the rootfs of containerID into a new layer and
+// registers the result as an image under ref. The container's active
+// snapshot is diffed against its parent to produce the new layer; the
+// parent image's config and manifest are used as the base for the new
+// image's config and manifest. This allows building images from
+// running or stopped containers without a Dockerfile.
+func (c *Client) Commit(ctx context.Context, containerID string, ref string, opts ...CommitOpts) (Image, error) {
+	var copts commitOpts
+	for _, o := range opts {
+		if err := o(&copts); err != nil {
+			return nil, err
+		}
+	}
+
+	container, err := c.ContainerService().Get(ctx, containerID)
+	if err != nil {
+		return nil, err
+	}
+	if container.Image == "" {
+		return nil, errors.Errorf("commit: container %s has no base image", containerID)
+	}
+
+	parent, err := c.ImageService().Get(ctx, container.Image)
+	if err != nil {
+		return nil, err
+	}
+
+	store := c.ContentStore()
+	parentManifest, err := readManifest(ctx, store, parent.Target)
+	if err != nil {
+		return nil, err
+	}
+	parentConfigBytes, err := content.ReadBlob(ctx, store, parentManifest.Config)
+	if err != nil {
+		return nil, err
+	}
+	var parentConfig ocispec.Image
+	if err := json.Unmarshal(parentConfigBytes, &parentConfig); err != nil {
+		return nil, errors.Wrap(err, "commit: parsing parent config")
+	}
+
+	snapshotter := c.SnapshotService(container.Snapshotter)
+	upper, err := snapshotter.Mounts(ctx, container.RootFS)
+	if err != nil {
+		return nil, err
+	}
+	info, err := snapshotter.Stat(ctx, container.RootFS)
+	if err != nil {
+		return nil, err
+	}
+
+	var lower []mount.Mount
+	if info.Parent != "" {
+		lower, err = snapshotter.Mounts(ctx, info.Parent)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	newLayer, err := c.DiffService().Compare(ctx, lower, upper, diff.WithMediaType(ocispec.MediaTypeImageLayerGzip))
+	if err != nil {
+		return nil, errors.Wrap(err, "commit: diffing rootfs")
+	}
+
+	diffID, err := diffIDOfLayer(ctx, store, newLayer)
+	if err != nil {
+		return nil, err
+	}
+
+	newConfig := parentConfig
+	newConfig.RootFS.DiffIDs = append(append([]digest.Digest(nil), parentConfig.RootFS.DiffIDs...), diffID)
+	now := time.Now()
+	newConfig.History = append(parentConfig.History, ocispec.History{
+		Created:   &now,
+		CreatedBy: copts.message,
+		Author:    copts.author,
+		Comment:   copts.message,
+	})
+	if copts.config != nil {
+		copts.config(&newConfig.Config)
+	}
+
+	newConfigJSON, err := json.Marshal(newConfig)
+	if err != nil {
+		return nil, err
+	}
+	newConfigDesc, err := writeBlobBytes(ctx, store, newConfigJSON, ocispec.MediaTypeImageConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := ocispec.Manifest{
+		Versioned: parentManifest.Versioned,
+		Config:    newConfigDesc,
+		Layers:    append(append([]ocispec.Descriptor(nil), parentManifest.Layers...), newLayer),
+	}
+	manifestJSON, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, err
+	}
+	manifestDesc, err := writeBlobBytes(ctx, store, manifestJSON, ocispec.MediaTypeImageManifest)
+	if err != nil {
+		return nil, err
+	}
+
+	imgrec := images.Image{
+		Name:   ref,
+		Target: manifestDesc,
+		Labels: copts.labels,
+	}
+	created, err := c.ImageService().Create(ctx, imgrec)
+	if err != nil {
+		if !errdefs.IsAlreadyExists(err) {
+			return nil, err
+		}
+		created, err = c.ImageService().Update(ctx, imgrec, "target", "labels")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &image{client: c, i: created}, nil
+}
+
+// diffIDOfLayer returns the uncompressed diffID of a layer blob, read from
+// its containerd.io/uncompressed label when present and otherwise computed
+// from the blob's uncompressed content. A computed value is not written
+// back as a label.
+func diffIDOfLayer(ctx context.Context, store content.Store, desc ocispec.Descriptor) (digest.Digest, error) {
+	info, err := store.Info(ctx, desc.Digest)
+	if err != nil {
+		return "", err
+	}
+	if d, ok := info.Labels["containerd.io/uncompressed"]; ok {
+		return digest.Parse(d)
+	}
+	// No label: digest the layer's plain tar content, gunzipping it first
+	// for gzip media types.
+	b, err := plainLayerTar(ctx, store, desc)
+	if err != nil {
+		return "", err
+	}
+	return digest.FromBytes(b), nil
+}
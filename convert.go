@@ -0,0 +1,171 @@
+package containerd
+
+import (
+	"context"
+	"sync"
+
+	"github.com/containerd/containerd/errdefs"
+	"github.com/containerd/containerd/pkg/converter"
+	"github.com/pkg/errors"
+)
+
+// ConvertOpts allows the caller to configure a conversion pipeline.
+type ConvertOpts func(*convertContext) error
+
+type convertContext struct {
+	*RemoteContext
+
+	rules       []ruleDriver
+	concurrency int
+	push        bool
+	events      chan<- converter.Event
+}
+
+type ruleDriver struct {
+	rule   converter.Rule
+	driver converter.Driver
+}
+
+func defaultConvertContext() *convertContext {
+	return &convertContext{
+		RemoteContext: defaultRemoteContext(),
+		concurrency:   1,
+	}
+}
+
+// WithConvertDriver registers driver against any image whose ref/labels
+// match rule. A single Convert call may register multiple drivers; every
+// matching driver runs.
+func WithConvertDriver(rule converter.Rule, driver converter.Driver) ConvertOpts {
+	return func(c *convertContext) error {
+		c.rules = append(c.rules, ruleDriver{rule: rule, driver: driver})
+		return nil
+	}
+}
+
+// WithConvertConcurrency bounds how many images are converted at once.
+func WithConvertConcurrency(n int) ConvertOpts {
+	return func(c *convertContext) error {
+		c.concurrency = n
+		return nil
+	}
+}
+
+// WithConvertPush re-pushes each converted image back to its registry
+// after conversion.
+func WithConvertPush() ConvertOpts {
+	return func(c *convertContext) error {
+		c.push = true
+		return nil
+	}
+}
+
+// WithConvertEvents streams conversion progress events to ch. The caller
+// owns ch and should drain it until Convert returns; Convert closes it
+// when the pipeline finishes.
+func WithConvertEvents(ch chan<- converter.Event) ConvertOpts {
+	return func(c *convertContext) error {
+		c.events = ch
+		return nil
+	}
+}
+
+// Convert transforms an image already present in the content store into
+// one or more alternative layouts, as selected by the registered
+// converter.Driver/converter.Rule pairs. It reuses the client's
+// RemoteContext (Resolver, BaseHandlers, Snapshotter) to pull ref if it is
+// not yet present, and writes converted manifests through
+// ImageService().Create. It returns the image produced by the first
+// registered driver that matched and converted ref; if more than one
+// driver matches, use WithConvertEvents to observe the rest.
+func (c *Client) Convert(ctx context.Context, ref string, opts ...ConvertOpts) (Image, error) {
+	cctx := defaultConvertContext()
+	for _, o := range opts {
+		if err := o(cctx); err != nil {
+			return nil, err
+		}
+	}
+	if len(cctx.rules) == 0 {
+		return nil, errors.New("convert: no drivers registered")
+	}
+
+	src, err := c.GetImage(ctx, ref)
+	if err != nil {
+		if !errdefs.IsNotFound(err) {
+			return nil, err
+		}
+		pullOpts := []RemoteOpts{WithResolver(cctx.Resolver), WithPullSnapshotter(cctx.Snapshotter)}
+		for _, h := range cctx.BaseHandlers {
+			pullOpts = append(pullOpts, WithImageHandler(h))
+		}
+		src, err = c.Pull(ctx, ref, pullOpts...)
+		if err != nil {
+			return nil, errors.Wrapf(err, "convert: failed to resolve %s", ref)
+		}
+	}
+
+	labels := src.(*image).i.Labels
+
+	// convertedNames records the image name each driver actually created,
+	// keyed by driver name rather than by ref: unlike the shipped
+	// no-op RecompressDriver, a real driver's output name may differ from
+	// ref, so ref can't be used to look the result back up once the
+	// worker finishes.
+	var (
+		mu             sync.Mutex
+		convertedNames = map[string]string{}
+	)
+
+	worker := converter.NewWorker(cctx.concurrency, func(ctx context.Context, job converter.Job, driver converter.Driver) error {
+		out, err := driver.Convert(ctx, src)
+		if err != nil {
+			return err
+		}
+		converted, ok := out.(Image)
+		if !ok {
+			return errors.Errorf("convert: driver %s returned an incompatible image", driver.Name())
+		}
+
+		imgrec := converted.(*image).i
+		if _, err := c.ImageService().Create(ctx, imgrec); err != nil {
+			if !errdefs.IsAlreadyExists(err) {
+				return err
+			}
+			if _, err := c.ImageService().Update(ctx, imgrec, "target", "labels"); err != nil {
+				return err
+			}
+		}
+		if cctx.push {
+			if err := c.Push(ctx, converted.Name(), converted.Target()); err != nil {
+				return err
+			}
+		}
+
+		mu.Lock()
+		convertedNames[driver.Name()] = converted.Name()
+		mu.Unlock()
+		return nil
+	}, cctx.events)
+
+	for _, rd := range cctx.rules {
+		worker.AddRule(rd.rule, rd.driver)
+	}
+
+	job := converter.Job{Ref: ref, Labels: labels, Push: cctx.push}
+	if err := worker.Run(ctx, []converter.Job{job}); err != nil {
+		return nil, err
+	}
+
+	// Multiple registered drivers may match the same job; resolve the
+	// first one in registration order, since that's the deterministic
+	// choice a caller with a single matching driver (the common case)
+	// expects. Callers registering more than one driver for one ref should
+	// use WithConvertEvents to observe every conversion, since Convert's
+	// single-Image return can only reflect one of them.
+	for _, rd := range cctx.rules {
+		if name, ok := convertedNames[rd.driver.Name()]; ok {
+			return c.GetImage(ctx, name)
+		}
+	}
+	return nil, errors.Errorf("convert: no driver matched %s", ref)
+}
@@ -0,0 +1,277 @@
+package containerd
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/containerd/containerd/content"
+	"github.com/containerd/containerd/images"
+	"github.com/containerd/containerd/log"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+)
+
+// dockerMediaTypeTranslations maps OCI media types to their Docker schema2
+// equivalents, used when importing/exporting the `docker save` tar layout.
+var dockerMediaTypeTranslations = map[string]string{
+	ocispec.MediaTypeImageManifest:  images.MediaTypeDockerSchema2Manifest,
+	ocispec.MediaTypeImageConfig:    images.MediaTypeDockerSchema2Config,
+	ocispec.MediaTypeImageLayer:     images.MediaTypeDockerSchema2Layer,
+	ocispec.MediaTypeImageLayerGzip: images.MediaTypeDockerSchema2LayerGzip,
+}
+
+func toDockerMediaType(mt string) string {
+	if v, ok := dockerMediaTypeTranslations[mt]; ok {
+		return v
+	}
+	return mt
+}
+
+func toOCIMediaType(mt string) string {
+	for oci, docker := range dockerMediaTypeTranslations {
+		if docker == mt {
+			return oci
+		}
+	}
+	return mt
+}
+
+// dockerManifestEntry is a single element of the top-level manifest.json in
+// a `docker save` tar archive.
+type dockerManifestEntry struct {
+	Config   string
+	RepoTags []string
+	Layers   []string
+}
+
+// importFromDockerTar imports an image from a `docker save`/`podman save`
+// tar stream: a top-level manifest.json, one <config-digest>.json, and one
+// <layer-digest>/layer.tar per layer. Blobs are ingested under the Docker
+// schema2 media types written by docker/podman unless
+// WithImportMediaTypeTranslation is given, in which case they are
+// translated to their OCI equivalents as they're written.
+func (c *Client) importFromDockerTar(ctx context.Context, ref string, reader io.Reader, iopts importOpts) (Image, error) {
+	store := c.ContentStore()
+	tr := tar.NewReader(reader)
+
+	var tracker *progressTracker
+	if iopts.progress != nil {
+		tracker = newProgressTracker(ctx, ref, store, iopts.progress)
+		defer tracker.Close()
+	}
+
+	layerMediaType := images.MediaTypeDockerSchema2Layer
+	configMediaType := images.MediaTypeDockerSchema2Config
+	manifestMediaType := images.MediaTypeDockerSchema2Manifest
+	if iopts.translateMediaTypes {
+		layerMediaType = toOCIMediaType(layerMediaType)
+		configMediaType = toOCIMediaType(configMediaType)
+		manifestMediaType = toOCIMediaType(manifestMediaType)
+	}
+
+	// layerTarToDigest maps the archive-relative layer.tar path to the
+	// digest of the blob written for it, since docker names layer
+	// directories by an ad-hoc, non-content id.
+	layerTarToDigest := map[string]digest.Digest{}
+	configBlobs := map[string][]byte{}
+	var manifestEntries []dockerManifestEntry
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "docker tar: reading header")
+		}
+
+		switch {
+		case hdr.Name == "manifest.json":
+			var b strings.Builder
+			if _, err := io.Copy(&b, tr); err != nil {
+				return nil, errors.Wrap(err, "docker tar: reading manifest.json")
+			}
+			if err := json.Unmarshal([]byte(b.String()), &manifestEntries); err != nil {
+				return nil, errors.Wrap(err, "docker tar: parsing manifest.json")
+			}
+		case strings.HasSuffix(hdr.Name, "/layer.tar"):
+			// docker save writes layer.tar uncompressed. The layer is
+			// buffered and its digest tracked before the ingest starts, so
+			// tracker's poll can report the ingest while it is active.
+			var buf bytes.Buffer
+			if _, err := io.CopyN(&buf, tr, hdr.Size); err != nil && err != io.EOF {
+				return nil, errors.Wrapf(err, "docker tar: reading layer %s", hdr.Name)
+			}
+			dgst := digest.FromBytes(buf.Bytes())
+			if tracker != nil {
+				tracker.track(dgst)
+			}
+			if _, err := writeBlobBytes(ctx, store, buf.Bytes(), layerMediaType); err != nil {
+				return nil, errors.Wrapf(err, "docker tar: writing layer %s", hdr.Name)
+			}
+			layerTarToDigest[hdr.Name] = dgst
+		case strings.HasSuffix(hdr.Name, ".json") && hdr.Name != "manifest.json":
+			var b strings.Builder
+			if _, err := io.Copy(&b, tr); err != nil {
+				return nil, errors.Wrapf(err, "docker tar: reading %s", hdr.Name)
+			}
+			configBlobs[hdr.Name] = []byte(b.String())
+		default:
+			// VERSION, repositories, and other legacy metadata files are ignored.
+			log.G(ctx).WithField("name", hdr.Name).Debug("docker tar: skipping unrecognized entry")
+		}
+	}
+
+	if len(manifestEntries) == 0 {
+		return nil, errors.New("docker tar: missing manifest.json")
+	}
+	entry := manifestEntries[0]
+
+	configJSON, ok := configBlobs[entry.Config]
+	if !ok {
+		return nil, errors.Errorf("docker tar: missing config %s", entry.Config)
+	}
+	configDesc, err := writeBlobBytes(ctx, store, configJSON, configMediaType)
+	if err != nil {
+		return nil, errors.Wrap(err, "docker tar: writing config")
+	}
+
+	var layers []ocispec.Descriptor
+	for _, l := range entry.Layers {
+		dgst, ok := layerTarToDigest[l]
+		if !ok {
+			return nil, errors.Errorf("docker tar: missing layer %s", l)
+		}
+		info, err := store.Info(ctx, dgst)
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, ocispec.Descriptor{
+			MediaType: layerMediaType,
+			Digest:    dgst,
+			Size:      info.Size,
+		})
+	}
+
+	manifest := ocispec.Manifest{
+		Versioned: struct {
+			SchemaVersion int `json:"schemaVersion"`
+		}{SchemaVersion: 2},
+		Config: configDesc,
+		Layers: layers,
+	}
+	manifestJSON, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, err
+	}
+	manifestDesc, err := writeBlobBytes(ctx, store, manifestJSON, manifestMediaType)
+	if err != nil {
+		return nil, errors.Wrap(err, "docker tar: writing manifest")
+	}
+
+	imgrec := images.Image{
+		Name:   ref,
+		Target: manifestDesc,
+	}
+	created, err := c.ImageService().Create(ctx, imgrec)
+	if err != nil {
+		return nil, err
+	}
+	return &image{client: c, i: created}, nil
+}
+
+// exportToDockerTar writes desc out as a `docker save`-compatible tar
+// stream: a top-level manifest.json, the image config, and one
+// <layer-digest>/layer.tar per layer. Layers are gunzipped as needed since
+// docker save's layer.tar entries must be plain tar; media types are
+// translated from OCI to their Docker schema2 equivalents when
+// WithMediaTypeTranslation is given.
+func (c *Client) exportToDockerTar(ctx context.Context, desc ocispec.Descriptor, w io.Writer, eopts exportOpts) error {
+	store := c.ContentStore()
+
+	// Export reads existing blobs straight out of the store rather than
+	// through an Ingester, so there is nothing for progressTracker's
+	// content-store poller to observe; report per-layer start/done
+	// directly instead of spinning one up.
+	var progressCh chan<- ProgressUpdate = eopts.progress
+	if progressCh != nil {
+		defer close(progressCh)
+	}
+
+	manifest, err := readManifest(ctx, store, desc)
+	if err != nil {
+		return err
+	}
+
+	tw := tar.NewWriter(w)
+	defer tw.Close()
+
+	configBytes, err := content.ReadBlob(ctx, store, manifest.Config)
+	if err != nil {
+		return errors.Wrap(err, "docker tar: reading config")
+	}
+	configName := manifest.Config.Digest.Encoded() + ".json"
+	if err := writeTarEntry(tw, configName, configBytes); err != nil {
+		return err
+	}
+
+	entry := dockerManifestEntry{Config: configName}
+	// docker load/podman load use RepoTags to restore the image's name:tag;
+	// without it the loaded image comes back untagged. Per Export's doc
+	// comment, the caller puts the ref on desc's AnnotationRefName.
+	if ref, ok := desc.Annotations[ocispec.AnnotationRefName]; ok && ref != "" {
+		entry.RepoTags = []string{ref}
+	}
+	for _, l := range manifest.Layers {
+		// The translated type is only passed to plainLayerTar, which
+		// gunzips OCI and Docker gzip layer types alike; no media type is
+		// written to the archive, so its contents are the same either way.
+		if eopts.translateMediaTypes {
+			l.MediaType = toDockerMediaType(l.MediaType)
+		}
+		started := time.Now()
+		emitExportProgress(ctx, progressCh, l.Digest, "exporting", 0, l.Size, started)
+
+		// docker save's layer.tar entries must be plain tar regardless of
+		// how the blob is stored in the content store.
+		layerTar, err := plainLayerTar(ctx, store, l)
+		if err != nil {
+			emitExportProgress(ctx, progressCh, l.Digest, "error", 0, l.Size, started)
+			return errors.Wrapf(err, "docker tar: reading layer %s", l.Digest)
+		}
+		name := fmt.Sprintf("%s/layer.tar", l.Digest.Encoded())
+		if err := writeTarEntry(tw, name, layerTar); err != nil {
+			emitExportProgress(ctx, progressCh, l.Digest, "error", 0, l.Size, started)
+			return err
+		}
+		emitExportProgress(ctx, progressCh, l.Digest, "done", l.Size, l.Size, started)
+		entry.Layers = append(entry.Layers, name)
+	}
+
+	manifestJSON, err := json.Marshal([]dockerManifestEntry{entry})
+	if err != nil {
+		return err
+	}
+	if err := writeTarEntry(tw, "manifest.json", manifestJSON); err != nil {
+		return err
+	}
+	return nil
+}
+
+func emitExportProgress(ctx context.Context, ch chan<- ProgressUpdate, dgst digest.Digest, status string, offset, total int64, started time.Time) {
+	if ch == nil {
+		return
+	}
+	u := ProgressUpdate{Digest: dgst, Status: status, Offset: offset, Total: total, StartedAt: started, UpdatedAt: time.Now()}
+	select {
+	case ch <- u:
+	case <-ctx.Done():
+	}
+}
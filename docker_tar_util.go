@@ -0,0 +1,113 @@
+package containerd
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/containerd/containerd/content"
+	"github.com/containerd/containerd/errdefs"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+)
+
+// writeBlob copies exactly size bytes from r into store under mediaType,
+// returning the digest of the written content.
+func writeBlob(ctx context.Context, store content.Store, r io.Reader, size int64, mediaType string) (digest.Digest, error) {
+	var buf bytes.Buffer
+	if _, err := io.CopyN(&buf, r, size); err != nil && err != io.EOF {
+		return "", err
+	}
+	desc, err := writeBlobBytes(ctx, store, buf.Bytes(), mediaType)
+	if err != nil {
+		return "", err
+	}
+	return desc.Digest, nil
+}
+
+// writeBlobBytes ingests b into store under mediaType and returns its descriptor.
+func writeBlobBytes(ctx context.Context, store content.Store, b []byte, mediaType string) (ocispec.Descriptor, error) {
+	dgst := digest.FromBytes(b)
+	ref := "docker-tar-import-" + dgst.String()
+
+	w, err := store.Writer(ctx, content.WithRef(ref), content.WithDescriptor(ocispec.Descriptor{Size: int64(len(b)), Digest: dgst}))
+	if err != nil {
+		if errdefs.IsAlreadyExists(err) {
+			return ocispec.Descriptor{MediaType: mediaType, Digest: dgst, Size: int64(len(b))}, nil
+		}
+		return ocispec.Descriptor{}, err
+	}
+	defer w.Close()
+
+	if _, err := w.Write(b); err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	if err := w.Commit(ctx, int64(len(b)), dgst); err != nil && !errdefs.IsAlreadyExists(err) {
+		return ocispec.Descriptor{}, err
+	}
+
+	return ocispec.Descriptor{
+		MediaType: mediaType,
+		Digest:    dgst,
+		Size:      int64(len(b)),
+	}, nil
+}
+
+// readManifest fetches and decodes the OCI manifest referenced by desc.
+func readManifest(ctx context.Context, store content.Store, desc ocispec.Descriptor) (ocispec.Manifest, error) {
+	var manifest ocispec.Manifest
+	b, err := content.ReadBlob(ctx, store, desc)
+	if err != nil {
+		return manifest, err
+	}
+	if err := json.Unmarshal(b, &manifest); err != nil {
+		return manifest, err
+	}
+	return manifest, nil
+}
+
+func writeTarEntry(tw *tar.Writer, name string, b []byte) error {
+	if err := tw.WriteHeader(&tar.Header{
+		Name: name,
+		Size: int64(len(b)),
+		Mode: 0644,
+	}); err != nil {
+		return err
+	}
+	_, err := tw.Write(b)
+	return err
+}
+
+// plainLayerTar returns the uncompressed tar bytes for the layer blob desc.
+// docker save's <hash>/layer.tar entries must be plain tar even though
+// containerd normally stores layer blobs gzip-compressed, so this
+// transparently gunzips desc's content when its media type names a gzip
+// variant.
+func plainLayerTar(ctx context.Context, store content.Store, desc ocispec.Descriptor) ([]byte, error) {
+	ra, err := store.ReaderAt(ctx, desc)
+	if err != nil {
+		return nil, err
+	}
+	defer ra.Close()
+
+	r := io.Reader(io.NewSectionReader(ra, 0, desc.Size))
+	if strings.HasSuffix(desc.MediaType, "gzip") {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, errors.Wrap(err, "docker tar: opening gzip layer")
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, r); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,188 @@
+package containerd
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/url"
+
+	"github.com/BurntSushi/toml"
+	"github.com/pkg/errors"
+)
+
+// HostCapabilities is a bitmask of the operations a RegistryHost may be
+// used for.
+type HostCapabilities int
+
+const (
+	// HostCapabilityPull allows the host to be used for fetching content.
+	HostCapabilityPull HostCapabilities = 1 << iota
+	// HostCapabilityResolve allows the host to be used for resolving refs to descriptors.
+	HostCapabilityResolve
+	// HostCapabilityPush allows the host to be used for pushing content.
+	HostCapabilityPush
+)
+
+// Has reports whether c includes capability h.
+func (c HostCapabilities) Has(h HostCapabilities) bool {
+	return c&h != 0
+}
+
+// RegistryHost describes one candidate endpoint for a registry host name.
+// Client.Pull/Push try candidates in order, falling back to the next host
+// on 401/404/5xx responses, so a mirror can transparently front a registry.
+type RegistryHost struct {
+	// Scheme is the URL scheme to use when talking to Host, e.g. "https".
+	Scheme string
+	// Host is the host[:port] to dial.
+	Host string
+	// Path is a path prefix to prepend to the standard distribution API paths.
+	Path string
+	// Capabilities restricts which operations this host may be used for.
+	Capabilities HostCapabilities
+	// Client is the http.Client (including any RoundTripper carrying
+	// auth/TLS configuration) to use when talking to this host.
+	Client *http.Client
+}
+
+// HostsFunc resolves a registry host name (as parsed out of an image ref)
+// to an ordered list of candidate RegistryHosts.
+type HostsFunc func(host string) ([]RegistryHost, error)
+
+// WithRegistryHosts configures the resolver used by Pull/Push to consult
+// hosts for candidate endpoints instead of talking to the ref's host
+// directly, enabling transparent pull-through mirrors.
+func WithRegistryHosts(hosts HostsFunc) RemoteOpts {
+	return func(_ *Client, c *RemoteContext) error {
+		c.Hosts = hosts
+		return nil
+	}
+}
+
+// hostConfig is one candidate endpoint within a registry's host list.
+type hostConfig struct {
+	URL          string   `toml:"url"`
+	Capabilities []string `toml:"capabilities"`
+	CACert       string   `toml:"ca"`
+	SkipVerify   bool     `toml:"skip_verify"`
+}
+
+// registryConfig maps a single origin registry (the hostname an image ref
+// actually names) to the ordered list of endpoints that may serve it.
+// This mirrors containerd's own per-origin hosts.toml convention
+// (`<config_path>/<origin>/hosts.toml`) collapsed into one file, e.g.:
+//
+//	[[registry]]
+//	  origin = "docker.io"
+//
+//	  [[registry.host]]
+//	    url = "https://mirror.example.com"
+//	    capabilities = ["pull", "resolve"]
+//
+//	  [[registry.host]]
+//	    url = "https://registry-1.docker.io"
+type registryConfig struct {
+	Origin string       `toml:"origin"`
+	Hosts  []hostConfig `toml:"host"`
+}
+
+type hostsFile struct {
+	Registries []registryConfig `toml:"registry"`
+}
+
+// WithHostsFromConfig parses a TOML file at path mapping origin registries
+// to their candidate endpoints (see registryConfig) and returns a
+// RemoteOpts that installs the resulting HostsFunc, so pulls/pushes for one
+// origin can transparently be fronted by one or more mirrors.
+func WithHostsFromConfig(path string) RemoteOpts {
+	return func(client *Client, c *RemoteContext) error {
+		hosts, err := parseHostsFile(path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to parse hosts config %s", path)
+		}
+		c.Hosts = hosts
+		return nil
+	}
+}
+
+func parseHostsFile(path string) (HostsFunc, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f hostsFile
+	if err := toml.Unmarshal(b, &f); err != nil {
+		return nil, err
+	}
+
+	byHost := map[string][]RegistryHost{}
+	for _, reg := range f.Registries {
+		if reg.Origin == "" {
+			return nil, errors.New("hosts config: registry entry missing origin")
+		}
+		for _, cfg := range reg.Hosts {
+			host, err := toRegistryHost(cfg)
+			if err != nil {
+				return nil, errors.Wrapf(err, "hosts config: origin %q", reg.Origin)
+			}
+			byHost[reg.Origin] = append(byHost[reg.Origin], host)
+		}
+	}
+
+	return func(host string) ([]RegistryHost, error) {
+		return byHost[host], nil
+	}, nil
+}
+
+// toRegistryHost builds the RegistryHost a hosts.toml host entry describes.
+// cfg.URL is parsed as an absolute URL (scheme://host[:port][/path]); a
+// bare hostname with no scheme is accepted for backwards compatibility and
+// defaults to https.
+func toRegistryHost(cfg hostConfig) (RegistryHost, error) {
+	raw := cfg.URL
+	if !hasScheme(raw) {
+		raw = "https://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return RegistryHost{}, errors.Wrapf(err, "invalid host url %q", cfg.URL)
+	}
+
+	var caps HostCapabilities
+	for _, s := range cfg.Capabilities {
+		switch s {
+		case "pull":
+			caps |= HostCapabilityPull
+		case "resolve":
+			caps |= HostCapabilityResolve
+		case "push":
+			caps |= HostCapabilityPush
+		default:
+			return RegistryHost{}, errors.Errorf("unknown capability %q for host %q", s, cfg.URL)
+		}
+	}
+	if caps == 0 {
+		caps = HostCapabilityPull | HostCapabilityResolve | HostCapabilityPush
+	}
+
+	client := http.DefaultClient
+	if cfg.SkipVerify || cfg.CACert != "" {
+		rt, err := newHostRoundTripper(cfg)
+		if err != nil {
+			return RegistryHost{}, err
+		}
+		client = &http.Client{Transport: rt}
+	}
+
+	return RegistryHost{
+		Scheme:       u.Scheme,
+		Host:         u.Host,
+		Path:         u.Path,
+		Capabilities: caps,
+		Client:       client,
+	}, nil
+}
+
+func hasScheme(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && u.Scheme != ""
+}
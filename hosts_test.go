@@ -0,0 +1,121 @@
+package containerd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseHostsFile(t *testing.T) {
+	const config = `
+[[registry]]
+  origin = "docker.io"
+
+  [[registry.host]]
+    url = "https://mirror.example.com"
+    capabilities = ["pull", "resolve"]
+
+  [[registry.host]]
+    url = "registry-1.docker.io"
+
+[[registry]]
+  origin = "registry.example.com:5000"
+
+  [[registry.host]]
+    url = "https://internal-mirror.example.com"
+    capabilities = ["pull"]
+`
+	dir := t.TempDir()
+	path := filepath.Join(dir, "hosts.toml")
+	if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	hostsFn, err := parseHostsFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dockerHosts, err := hostsFn("docker.io")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dockerHosts) != 2 {
+		t.Fatalf("got %d hosts for docker.io, want 2", len(dockerHosts))
+	}
+	if got, want := dockerHosts[0].Host, "mirror.example.com"; got != want {
+		t.Errorf("hosts[0].Host = %q, want %q", got, want)
+	}
+	if !dockerHosts[0].Capabilities.Has(HostCapabilityPull) || !dockerHosts[0].Capabilities.Has(HostCapabilityResolve) {
+		t.Errorf("hosts[0].Capabilities = %v, want pull+resolve", dockerHosts[0].Capabilities)
+	}
+	if dockerHosts[0].Capabilities.Has(HostCapabilityPush) {
+		t.Errorf("hosts[0].Capabilities = %v, want push unset", dockerHosts[0].Capabilities)
+	}
+
+	// A bare hostname defaults to https and gets every capability.
+	if got, want := dockerHosts[1].Scheme, "https"; got != want {
+		t.Errorf("hosts[1].Scheme = %q, want %q", got, want)
+	}
+	if got, want := dockerHosts[1].Host, "registry-1.docker.io"; got != want {
+		t.Errorf("hosts[1].Host = %q, want %q", got, want)
+	}
+	if dockerHosts[1].Capabilities != (HostCapabilityPull | HostCapabilityResolve | HostCapabilityPush) {
+		t.Errorf("hosts[1].Capabilities = %v, want all capabilities", dockerHosts[1].Capabilities)
+	}
+
+	// A different origin must not see docker.io's mirrors: this is the
+	// origin -> candidates mapping the config schema exists to provide.
+	internalHosts, err := hostsFn("registry.example.com:5000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(internalHosts) != 1 || internalHosts[0].Host != "internal-mirror.example.com" {
+		t.Fatalf("got %v for registry.example.com:5000, want single internal-mirror.example.com host", internalHosts)
+	}
+
+	unknownHosts, err := hostsFn("unconfigured.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(unknownHosts) != 0 {
+		t.Errorf("got %d hosts for an unconfigured origin, want 0", len(unknownHosts))
+	}
+}
+
+func TestParseHostsFileRejectsUnknownCapability(t *testing.T) {
+	const config = `
+[[registry]]
+  origin = "docker.io"
+
+  [[registry.host]]
+    url = "https://mirror.example.com"
+    capabilities = ["pull", "bogus"]
+`
+	dir := t.TempDir()
+	path := filepath.Join(dir, "hosts.toml")
+	if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := parseHostsFile(path); err == nil {
+		t.Fatal("expected an error for an unknown capability, got nil")
+	}
+}
+
+func TestParseHostsFileRequiresOrigin(t *testing.T) {
+	const config = `
+[[registry]]
+  [[registry.host]]
+    url = "https://mirror.example.com"
+`
+	dir := t.TempDir()
+	path := filepath.Join(dir, "hosts.toml")
+	if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := parseHostsFile(path); err == nil {
+		t.Fatal("expected an error for a registry entry missing origin, got nil")
+	}
+}
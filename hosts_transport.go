@@ -0,0 +1,35 @@
+package containerd
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// newHostRoundTripper builds an http.RoundTripper honoring a hosts.toml
+// entry's TLS settings (custom CA, or skip_verify for self-signed mirrors).
+func newHostRoundTripper(cfg hostConfig) (http.RoundTripper, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: cfg.SkipVerify,
+	}
+
+	if cfg.CACert != "" {
+		pem, err := ioutil.ReadFile(cfg.CACert)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read ca cert %s", cfg.CACert)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.Errorf("failed to parse ca cert %s", cfg.CACert)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return &http.Transport{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: tlsConfig,
+	}, nil
+}
@@ -0,0 +1,73 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package converter provides a pluggable pipeline for transforming images
+// already present in the content store into alternative layouts, such as
+// lazy-loading or seekable-gzip formats.
+package converter
+
+import (
+	"context"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// Image is the minimal view of an image a Driver needs in order to read its
+// manifest/config and produce a converted one. It is satisfied by
+// containerd's own Image type.
+type Image interface {
+	// Name returns the reference the image was resolved from.
+	Name() string
+	// Target returns the descriptor for the image's manifest or index.
+	Target() ocispec.Descriptor
+}
+
+// Driver transforms a source image into a new image with an alternative
+// layout. Implementations are registered by callers and selected per
+// conversion request; containerd does not maintain a global registry.
+type Driver interface {
+	// Convert reads src from the content store and writes out a new
+	// manifest (and any new blobs it references), returning the
+	// resulting image.
+	Convert(ctx context.Context, src Image) (Image, error)
+
+	// Name returns a short, unique identifier for the driver, used in
+	// logs and progress events.
+	Name() string
+}
+
+// EventType describes the phase a conversion has reached.
+type EventType string
+
+const (
+	// EventMatched is emitted when a Rule selects a source image for conversion.
+	EventMatched EventType = "matched"
+	// EventStarted is emitted when a Worker begins converting a matched image.
+	EventStarted EventType = "started"
+	// EventCompleted is emitted when a conversion finishes successfully.
+	EventCompleted EventType = "completed"
+	// EventFailed is emitted when a conversion returns an error.
+	EventFailed EventType = "failed"
+)
+
+// Event reports the progress of a single image conversion. Callers passing
+// a channel to NewWorker receive a stream of these to drive UIs.
+type Event struct {
+	Type   EventType
+	Ref    string
+	Driver string
+	Err    error
+}
@@ -0,0 +1,38 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package converter
+
+import "context"
+
+// RecompressDriver is a proof-of-plumbing built-in Driver: it re-registers
+// the source image unchanged. It exists to exercise the Worker pool and
+// Rule matching without requiring a real lazy-loading or seekable-gzip
+// implementation.
+type RecompressDriver struct{}
+
+var _ Driver = (*RecompressDriver)(nil)
+
+// Name implements Driver.
+func (*RecompressDriver) Name() string {
+	return "recompress"
+}
+
+// Convert implements Driver. It performs no actual re-compression and
+// simply returns src, leaving real layer transformation to future drivers.
+func (*RecompressDriver) Convert(ctx context.Context, src Image) (Image, error) {
+	return src, nil
+}
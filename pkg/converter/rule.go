@@ -0,0 +1,61 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package converter
+
+import (
+	"strings"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// PlatformMatcher reports whether a platform is eligible for conversion.
+type PlatformMatcher func(platform ocispec.Platform) bool
+
+// Rule decides whether a source ref/manifest is eligible for a Driver.
+// A zero-value Rule matches everything.
+type Rule struct {
+	// TagSuffix, if set, only matches refs ending in this suffix
+	// (e.g. "-nydus" matches "repo:latest-nydus" but not "repo:latest").
+	TagSuffix string
+
+	// LabelSelector, if set, only matches images carrying all of these
+	// labels with equal values.
+	LabelSelector map[string]string
+
+	// PlatformMatcher, if set, only matches manifests for platforms it
+	// returns true for.
+	PlatformMatcher PlatformMatcher
+}
+
+// Match reports whether ref, its image labels, and platform (if any)
+// satisfy the rule.
+func (r Rule) Match(ref string, labels map[string]string, platform *ocispec.Platform) bool {
+	if r.TagSuffix != "" && !strings.HasSuffix(ref, r.TagSuffix) {
+		return false
+	}
+	for k, v := range r.LabelSelector {
+		if labels[k] != v {
+			return false
+		}
+	}
+	if r.PlatformMatcher != nil {
+		if platform == nil || !r.PlatformMatcher(*platform) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,121 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package converter
+
+import (
+	"testing"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestRuleMatch(t *testing.T) {
+	amd64 := &ocispec.Platform{OS: "linux", Architecture: "amd64"}
+	arm64 := &ocispec.Platform{OS: "linux", Architecture: "arm64"}
+
+	tests := []struct {
+		name     string
+		rule     Rule
+		ref      string
+		labels   map[string]string
+		platform *ocispec.Platform
+		want     bool
+	}{
+		{
+			name: "zero value matches everything",
+			rule: Rule{},
+			ref:  "example.com/repo:latest",
+			want: true,
+		},
+		{
+			name: "tag suffix matches",
+			rule: Rule{TagSuffix: "-nydus"},
+			ref:  "example.com/repo:latest-nydus",
+			want: true,
+		},
+		{
+			name: "tag suffix mismatch",
+			rule: Rule{TagSuffix: "-nydus"},
+			ref:  "example.com/repo:latest",
+			want: false,
+		},
+		{
+			name:   "label selector matches",
+			rule:   Rule{LabelSelector: map[string]string{"convert": "nydus"}},
+			ref:    "example.com/repo:latest",
+			labels: map[string]string{"convert": "nydus", "extra": "ignored"},
+			want:   true,
+		},
+		{
+			name:   "label selector value mismatch",
+			rule:   Rule{LabelSelector: map[string]string{"convert": "nydus"}},
+			ref:    "example.com/repo:latest",
+			labels: map[string]string{"convert": "estargz"},
+			want:   false,
+		},
+		{
+			name:   "label selector missing key",
+			rule:   Rule{LabelSelector: map[string]string{"convert": "nydus"}},
+			ref:    "example.com/repo:latest",
+			labels: map[string]string{},
+			want:   false,
+		},
+		{
+			name: "platform matcher matches",
+			rule: Rule{PlatformMatcher: func(p ocispec.Platform) bool {
+				return p.Architecture == "amd64"
+			}},
+			ref:      "example.com/repo:latest",
+			platform: amd64,
+			want:     true,
+		},
+		{
+			name: "platform matcher mismatch",
+			rule: Rule{PlatformMatcher: func(p ocispec.Platform) bool {
+				return p.Architecture == "amd64"
+			}},
+			ref:      "example.com/repo:latest",
+			platform: arm64,
+			want:     false,
+		},
+		{
+			name: "platform matcher with nil platform",
+			rule: Rule{PlatformMatcher: func(p ocispec.Platform) bool {
+				return p.Architecture == "amd64"
+			}},
+			ref:  "example.com/repo:latest",
+			want: false,
+		},
+		{
+			name: "all conditions must hold",
+			rule: Rule{
+				TagSuffix:     "-nydus",
+				LabelSelector: map[string]string{"convert": "nydus"},
+			},
+			ref:    "example.com/repo:latest-nydus",
+			labels: map[string]string{"convert": "estargz"},
+			want:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rule.Match(tt.ref, tt.labels, tt.platform); got != tt.want {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
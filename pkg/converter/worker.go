@@ -0,0 +1,140 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package converter
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Job is a single conversion request submitted to a Worker pool.
+type Job struct {
+	// Ref is the source image reference to convert.
+	Ref string
+	// Labels are the source image's labels, used for Rule matching.
+	Labels map[string]string
+	// Push, if true, re-pushes the converted image back to ref's registry.
+	Push bool
+}
+
+// Task fetches, converts, and optionally re-pushes a single Job. Callers
+// supply this so the Worker pool stays agnostic of how images are pulled
+// and pushed.
+type Task func(ctx context.Context, job Job, driver Driver) error
+
+// Worker runs Jobs matching Rules against Drivers with bounded concurrency.
+type Worker struct {
+	concurrency int
+	rules       []ruleDriver
+	events      chan<- Event
+	task        Task
+}
+
+type ruleDriver struct {
+	rule   Rule
+	driver Driver
+}
+
+// NewWorker creates a Worker pool that runs up to concurrency Jobs at once.
+// events, if non-nil, receives progress Events as Jobs are matched and
+// processed; the caller should drain it while Run is in flight. Run closes
+// events once every Job has finished.
+func NewWorker(concurrency int, task Task, events chan<- Event) *Worker {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	return &Worker{
+		concurrency: concurrency,
+		task:        task,
+		events:      events,
+	}
+}
+
+// AddRule registers driver to run against any Job whose ref/labels match rule.
+// A Job may match multiple rules; each matching driver is run.
+func (w *Worker) AddRule(rule Rule, driver Driver) {
+	w.rules = append(w.rules, ruleDriver{rule: rule, driver: driver})
+}
+
+// Run dispatches jobs across the pool, blocking until every started task
+// has returned. ctx is passed to each task; cancelling it does not make Run
+// return before those tasks do. Errors from individual jobs are collected
+// and returned together.
+func (w *Worker) Run(ctx context.Context, jobs []Job) error {
+	sem := make(chan struct{}, w.concurrency)
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+
+	emit := func(e Event) {
+		if w.events == nil {
+			return
+		}
+		select {
+		case w.events <- e:
+		case <-ctx.Done():
+		}
+	}
+
+	for _, job := range jobs {
+		matched := w.matchDrivers(job)
+		for _, rd := range matched {
+			emit(Event{Type: EventMatched, Ref: job.Ref, Driver: rd.driver.Name()})
+
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(job Job, driver Driver) {
+				defer wg.Done()
+				defer func() { <-sem }()
+
+				emit(Event{Type: EventStarted, Ref: job.Ref, Driver: driver.Name()})
+				if err := w.task(ctx, job, driver); err != nil {
+					emit(Event{Type: EventFailed, Ref: job.Ref, Driver: driver.Name(), Err: err})
+					mu.Lock()
+					errs = append(errs, errors.Wrapf(err, "convert %s with %s", job.Ref, driver.Name()))
+					mu.Unlock()
+					return
+				}
+				emit(Event{Type: EventCompleted, Ref: job.Ref, Driver: driver.Name()})
+			}(job, rd.driver)
+		}
+	}
+
+	wg.Wait()
+	if w.events != nil {
+		close(w.events)
+	}
+
+	if len(errs) > 0 {
+		return errors.Errorf("%d job(s) failed: %v", len(errs), errs)
+	}
+	return nil
+}
+
+func (w *Worker) matchDrivers(job Job) []ruleDriver {
+	var out []ruleDriver
+	for _, rd := range w.rules {
+		if rd.rule.Match(job.Ref, job.Labels, nil) {
+			out = append(out, rd)
+		}
+	}
+	return out
+}
@@ -0,0 +1,69 @@
+package containerd
+
+import (
+	"context"
+	"runtime"
+
+	"github.com/containerd/containerd/content"
+	"github.com/containerd/containerd/errdefs"
+	"github.com/containerd/containerd/images"
+	"github.com/containerd/containerd/platforms"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+)
+
+// platformFilterHandler wraps images.ChildrenHandler so that, when it
+// encounters a manifest-list/index, only the children matching
+// pullCtx.Platforms (or every child, if AllPlatforms is set) are returned
+// for further dispatch. matched collects the manifest descriptors that
+// were selected at the index's own level, so Pull can unpack each of them
+// afterwards. Descriptors below the index (manifests, layers, config) pass
+// through unfiltered.
+func platformFilterHandler(pullCtx *RemoteContext, store content.Provider, matched *[]ocispec.Descriptor) images.HandlerFunc {
+	children := images.ChildrenHandler(store)
+
+	wanted := pullCtx.Platforms
+	if len(wanted) == 0 && !pullCtx.AllPlatforms {
+		wanted = []ocispec.Platform{{OS: runtime.GOOS, Architecture: runtime.GOARCH}}
+	}
+
+	return func(ctx context.Context, desc ocispec.Descriptor) ([]ocispec.Descriptor, error) {
+		out, err := children(ctx, desc)
+		if err != nil {
+			return nil, err
+		}
+
+		switch desc.MediaType {
+		case images.MediaTypeDockerSchema2ManifestList, ocispec.MediaTypeImageIndex:
+		default:
+			return out, nil
+		}
+
+		if pullCtx.AllPlatforms {
+			*matched = append(*matched, out...)
+			return out, nil
+		}
+
+		var filtered []ocispec.Descriptor
+		for _, d := range out {
+			if d.Platform == nil || matchesAnyPlatform(*d.Platform, wanted) {
+				filtered = append(filtered, d)
+			}
+		}
+		if len(filtered) == 0 {
+			return nil, errors.Wrapf(errdefs.ErrNotFound, "no manifest matching requested platform(s) in %s", desc.Digest)
+		}
+
+		*matched = append(*matched, filtered...)
+		return filtered, nil
+	}
+}
+
+func matchesAnyPlatform(p ocispec.Platform, wanted []ocispec.Platform) bool {
+	for _, w := range wanted {
+		if platforms.NewMatcher(w).Match(p) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,298 @@
+package containerd
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/containerd/containerd/content"
+	"github.com/containerd/containerd/remotes"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+)
+
+// errClosedBeforeDone is reported on the "done"/"error" event a tracked
+// reader/writer emits if Close (not EOF/Commit) is what ended the
+// transfer, e.g. because a sibling blob in the same dispatch failed or ctx
+// was cancelled mid-transfer.
+var errClosedBeforeDone = errors.New("closed before transfer completed")
+
+// ProgressUpdate reports the transfer state of a single descriptor during
+// Pull, Push, Import, or Export. Consumers receive a stream of these on
+// the channel passed to WithProgress/WithImportProgress/WithExportProgress
+// instead of having to poll the content store themselves.
+type ProgressUpdate struct {
+	Ref       string
+	Digest    digest.Digest
+	Status    string
+	Offset    int64
+	Total     int64
+	StartedAt time.Time
+	UpdatedAt time.Time
+	Err       error
+}
+
+// progressPollInterval is how often the content store's active ingests are
+// polled to surface downloads that aren't observed any other way, e.g.
+// importFromDockerTar's direct layer writes. Transfers driven through
+// trackFetcher/trackPusher are reported solely by the wrapped reader/writer
+// and must not also call track, or the same descriptor is reported twice
+// via two independent, unsynchronized paths.
+const progressPollInterval = 100 * time.Millisecond
+
+// progressTracker coalesces updates from a wrapped fetcher/pusher and from
+// polling the content store into a single event stream, closing ch when
+// Close is called.
+type progressTracker struct {
+	ch  chan<- ProgressUpdate
+	ref string
+
+	mu      sync.Mutex
+	tracked map[digest.Digest]struct{}
+
+	store content.Store
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func newProgressTracker(ctx context.Context, ref string, store content.Store, ch chan<- ProgressUpdate) *progressTracker {
+	pctx, cancel := context.WithCancel(ctx)
+	t := &progressTracker{
+		ch:      ch,
+		ref:     ref,
+		tracked: make(map[digest.Digest]struct{}),
+		store:   store,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+	go t.poll(pctx)
+	return t
+}
+
+// track records that dgst belongs to this operation, so poll only reports
+// ingests this tracker is actually responsible for, not every blob being
+// ingested concurrently by unrelated transfers sharing the content store.
+// Only call this for ingests not already observed through a
+// trackedReadCloser/trackedWriter (see progressPollInterval's doc).
+func (t *progressTracker) track(dgst digest.Digest) {
+	t.mu.Lock()
+	t.tracked[dgst] = struct{}{}
+	t.mu.Unlock()
+}
+
+func (t *progressTracker) isTracked(dgst digest.Digest) bool {
+	t.mu.Lock()
+	_, ok := t.tracked[dgst]
+	t.mu.Unlock()
+	return ok
+}
+
+func (t *progressTracker) poll(ctx context.Context) {
+	defer close(t.done)
+	ticker := time.NewTicker(progressPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			statuses, err := t.store.ListStatuses(ctx)
+			if err != nil {
+				continue
+			}
+			for _, s := range statuses {
+				if !t.isTracked(s.Expected) {
+					continue
+				}
+				t.emit(ctx, ProgressUpdate{
+					Ref:       t.ref,
+					Digest:    s.Expected,
+					Status:    "downloading",
+					Offset:    s.Offset,
+					Total:     s.Total,
+					StartedAt: s.StartedAt,
+					UpdatedAt: s.UpdatedAt,
+				})
+			}
+		}
+	}
+}
+
+// emit forwards u to the caller's channel, dropping the update instead of
+// blocking forever if the consumer has stopped reading and ctx is done.
+func (t *progressTracker) emit(ctx context.Context, u ProgressUpdate) {
+	if t.ch == nil {
+		return
+	}
+	select {
+	case t.ch <- u:
+	case <-ctx.Done():
+	}
+}
+
+// Close stops polling and closes the update channel. Call after dispatch
+// finishes.
+func (t *progressTracker) Close() {
+	t.cancel()
+	<-t.done
+	if t.ch != nil {
+		close(t.ch)
+	}
+}
+
+// trackFetcher wraps a remotes.Fetcher so that every Fetch emits a
+// started/completed ProgressUpdate and each Read increments the reported
+// byte offset for that descriptor.
+func trackFetcher(f remotes.Fetcher, t *progressTracker) remotes.Fetcher {
+	return &trackedFetcher{Fetcher: f, t: t}
+}
+
+type trackedFetcher struct {
+	remotes.Fetcher
+	t *progressTracker
+}
+
+func (f *trackedFetcher) Fetch(ctx context.Context, desc ocispec.Descriptor) (io.ReadCloser, error) {
+	started := time.Now()
+	f.t.emit(ctx, ProgressUpdate{Ref: f.t.ref, Digest: desc.Digest, Status: "downloading", Total: desc.Size, StartedAt: started, UpdatedAt: started})
+
+	rc, err := f.Fetcher.Fetch(ctx, desc)
+	if err != nil {
+		f.t.emit(ctx, ProgressUpdate{Ref: f.t.ref, Digest: desc.Digest, Status: "error", Err: err, StartedAt: started, UpdatedAt: time.Now()})
+		return nil, err
+	}
+	return &trackedReadCloser{
+		ReadCloser: rc,
+		onProgress: func(n int64) {
+			f.t.emit(ctx, ProgressUpdate{Ref: f.t.ref, Digest: desc.Digest, Status: "downloading", Offset: n, Total: desc.Size, StartedAt: started, UpdatedAt: time.Now()})
+		},
+		onDone: func(offset int64, err error) {
+			status := "done"
+			if err != nil {
+				status = "error"
+			}
+			f.t.emit(ctx, ProgressUpdate{Ref: f.t.ref, Digest: desc.Digest, Status: status, Offset: offset, Total: desc.Size, Err: err, StartedAt: started, UpdatedAt: time.Now()})
+		},
+	}, nil
+}
+
+// trackPusher wraps a remotes.Pusher the same way trackFetcher wraps a
+// remotes.Fetcher, tracking upload progress instead of download progress.
+func trackPusher(p remotes.Pusher, t *progressTracker) remotes.Pusher {
+	return &trackedPusher{Pusher: p, t: t}
+}
+
+type trackedPusher struct {
+	remotes.Pusher
+	t *progressTracker
+}
+
+func (p *trackedPusher) Push(ctx context.Context, desc ocispec.Descriptor) (content.Writer, error) {
+	started := time.Now()
+	p.t.emit(ctx, ProgressUpdate{Ref: p.t.ref, Digest: desc.Digest, Status: "uploading", Total: desc.Size, StartedAt: started, UpdatedAt: started})
+
+	w, err := p.Pusher.Push(ctx, desc)
+	if err != nil {
+		p.t.emit(ctx, ProgressUpdate{Ref: p.t.ref, Digest: desc.Digest, Status: "error", Err: err, StartedAt: started, UpdatedAt: time.Now()})
+		return nil, err
+	}
+	onDone := func(offset int64, err error) {
+		status := "done"
+		if err != nil {
+			status = "error"
+		}
+		p.t.emit(ctx, ProgressUpdate{Ref: p.t.ref, Digest: desc.Digest, Status: status, Offset: offset, Total: desc.Size, Err: err, StartedAt: started, UpdatedAt: time.Now()})
+	}
+	return &trackedWriter{
+		Writer: w,
+		onProgress: func(n int64) {
+			p.t.emit(ctx, ProgressUpdate{Ref: p.t.ref, Digest: desc.Digest, Status: "uploading", Offset: n, Total: desc.Size, StartedAt: started, UpdatedAt: time.Now()})
+		},
+		onDone: onDone,
+	}, nil
+}
+
+type trackedReadCloser struct {
+	io.ReadCloser
+	offset     int64
+	reachedEOF bool
+	onProgress func(int64)
+	onDone     func(offset int64, err error)
+	doneOnce   sync.Once
+}
+
+func (r *trackedReadCloser) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		r.offset += int64(n)
+		r.onProgress(r.offset)
+	}
+	if err != nil {
+		doneErr := err
+		if err == io.EOF {
+			doneErr = nil
+			r.reachedEOF = true
+		}
+		r.doneOnce.Do(func() { r.onDone(r.offset, doneErr) })
+	}
+	return n, err
+}
+
+// Close reports the transfer done only if Read already reached EOF; a
+// Close that preempts EOF (a sibling blob in the same dispatch failed, ctx
+// was cancelled, ...) reports the actual bytes read so far and
+// errClosedBeforeDone instead of falsely claiming the full descriptor.
+func (r *trackedReadCloser) Close() error {
+	r.doneOnce.Do(func() {
+		var err error
+		if !r.reachedEOF {
+			err = errClosedBeforeDone
+		}
+		r.onDone(r.offset, err)
+	})
+	return r.ReadCloser.Close()
+}
+
+type trackedWriter struct {
+	content.Writer
+	offset     int64
+	committed  bool
+	onProgress func(int64)
+	onDone     func(offset int64, err error)
+	doneOnce   sync.Once
+}
+
+func (w *trackedWriter) Write(p []byte) (int, error) {
+	n, err := w.Writer.Write(p)
+	if n > 0 {
+		w.offset += int64(n)
+		w.onProgress(w.offset)
+	}
+	return n, err
+}
+
+func (w *trackedWriter) Commit(ctx context.Context, size int64, expected digest.Digest, opts ...content.Opt) error {
+	err := w.Writer.Commit(ctx, size, expected, opts...)
+	if err == nil {
+		w.committed = true
+	}
+	w.doneOnce.Do(func() { w.onDone(w.offset, err) })
+	return err
+}
+
+// Close reports the transfer done only if Commit already succeeded; a
+// Close that preempts Commit reports the actual bytes written so far and
+// errClosedBeforeDone instead of falsely claiming the full descriptor.
+func (w *trackedWriter) Close() error {
+	w.doneOnce.Do(func() {
+		var err error
+		if !w.committed {
+			err = errClosedBeforeDone
+		}
+		w.onDone(w.offset, err)
+	})
+	return w.Writer.Close()
+}
@@ -0,0 +1,235 @@
+package containerd
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+
+	"github.com/containerd/containerd/content"
+	"github.com/containerd/containerd/errdefs"
+	"github.com/containerd/containerd/reference"
+	"github.com/containerd/containerd/remotes"
+	"github.com/containerd/containerd/remotes/docker"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+)
+
+// resolverForRef builds the remotes.Resolver Pull/Push should use for ref.
+// If rc.Resolver is set explicitly it always wins; otherwise, if rc.Hosts
+// is set, candidate hosts are tried in order, falling back to the next
+// host on 401/404/5xx, so a mirror can transparently front a registry.
+func resolverForRef(rc *RemoteContext, ref string) (remotes.Resolver, error) {
+	if rc.Resolver != nil {
+		return rc.Resolver, nil
+	}
+	if rc.Hosts == nil {
+		return docker.NewResolver(docker.ResolverOptions{
+			Client: http.DefaultClient,
+		}), nil
+	}
+
+	refspec, err := reference.Parse(ref)
+	if err != nil {
+		return nil, err
+	}
+	hosts, err := rc.Hosts(refspec.Hostname())
+	if err != nil {
+		return nil, err
+	}
+	if len(hosts) == 0 {
+		return docker.NewResolver(docker.ResolverOptions{
+			Client: http.DefaultClient,
+		}), nil
+	}
+	return &mirrorResolver{hosts: hosts}, nil
+}
+
+// mirrorResolver tries a list of RegistryHost candidates in order for
+// each operation, falling back to the next candidate on 401, 404, and 5xx
+// responses from the current one.
+type mirrorResolver struct {
+	hosts []RegistryHost
+}
+
+func (m *mirrorResolver) resolversFor(cap HostCapabilities) []remotes.Resolver {
+	var out []remotes.Resolver
+	for _, h := range m.hosts {
+		if !h.Capabilities.Has(cap) {
+			continue
+		}
+		host := h
+		client := host.Client
+		if client == nil {
+			client = http.DefaultClient
+		}
+		out = append(out, docker.NewResolver(docker.ResolverOptions{
+			Client: client,
+			Host: func(string) (string, error) {
+				return host.Scheme + "://" + host.Host + host.Path, nil
+			},
+		}))
+	}
+	return out
+}
+
+// unexpectedStatusCodeRe extracts the HTTP status code docker.Resolver
+// embeds in the error text for any non-404 failure response, e.g.
+// `unexpected status code https://host/v2/...: 401 Unauthorized`. 404 is
+// the only status docker.Resolver surfaces as a typed error
+// (errdefs.ErrNotFound); everything else only appears in the message.
+var unexpectedStatusCodeRe = regexp.MustCompile(`unexpected status code .*: (\d{3})`)
+
+// statusCodeFromErr recovers the HTTP status code backing err, if any.
+func statusCodeFromErr(err error) (int, bool) {
+	if m := unexpectedStatusCodeRe.FindStringSubmatch(err.Error()); m != nil {
+		if code, convErr := strconv.Atoi(m[1]); convErr == nil {
+			return code, true
+		}
+	}
+	return 0, false
+}
+
+// isFallbackErr reports whether err from one candidate host should cause
+// the next host to be tried, rather than aborting the whole resolve.
+// Per resolverForRef's doc, only 401, 404, and 5xx responses fall
+// through to the next host; anything else (malformed refs, TLS failures,
+// a cancelled context) aborts immediately so it isn't masked by silently
+// retrying every remaining host.
+func isFallbackErr(err error) bool {
+	if err == nil {
+		return false
+	}
+	cause := errors.Cause(err)
+	if cause == context.Canceled || cause == context.DeadlineExceeded {
+		return false
+	}
+	if errdefs.IsNotFound(cause) {
+		return true
+	}
+	if code, ok := statusCodeFromErr(cause); ok {
+		return code == http.StatusUnauthorized || code >= 500
+	}
+	return false
+}
+
+func (m *mirrorResolver) Resolve(ctx context.Context, ref string) (string, ocispec.Descriptor, error) {
+	var lastErr error
+	for _, r := range m.resolversFor(HostCapabilityResolve) {
+		name, desc, err := r.Resolve(ctx, ref)
+		if err == nil {
+			return name, desc, nil
+		}
+		lastErr = err
+		if !isFallbackErr(err) {
+			return "", ocispec.Descriptor{}, err
+		}
+	}
+	if lastErr == nil {
+		lastErr = errors.Errorf("no registry host configured to resolve %s", ref)
+	}
+	return "", ocispec.Descriptor{}, lastErr
+}
+
+// Fetcher builds a remotes.Fetcher backed by every candidate host capable
+// of pulling ref, in order. docker.Resolver.Fetcher only builds a struct
+// referencing the host and does no network I/O, so constructing one per
+// candidate up front is cheap; the actual 401/404/5xx fallback happens
+// later in mirrorFetcher.Fetch, once a real blob request is made.
+func (m *mirrorResolver) Fetcher(ctx context.Context, ref string) (remotes.Fetcher, error) {
+	var fetchers []remotes.Fetcher
+	var lastErr error
+	for _, r := range m.resolversFor(HostCapabilityPull) {
+		f, err := r.Fetcher(ctx, ref)
+		if err != nil {
+			lastErr = err
+			if !isFallbackErr(err) {
+				return nil, err
+			}
+			continue
+		}
+		fetchers = append(fetchers, f)
+	}
+	if len(fetchers) == 0 {
+		if lastErr == nil {
+			lastErr = errors.Errorf("no registry host configured to fetch %s", ref)
+		}
+		return nil, lastErr
+	}
+	return &mirrorFetcher{fetchers: fetchers}, nil
+}
+
+// Pusher builds a remotes.Pusher backed by every candidate host capable of
+// pushing ref, in order, for the same reason Fetcher does.
+func (m *mirrorResolver) Pusher(ctx context.Context, ref string) (remotes.Pusher, error) {
+	var pushers []remotes.Pusher
+	var lastErr error
+	for _, r := range m.resolversFor(HostCapabilityPush) {
+		p, err := r.Pusher(ctx, ref)
+		if err != nil {
+			lastErr = err
+			if !isFallbackErr(err) {
+				return nil, err
+			}
+			continue
+		}
+		pushers = append(pushers, p)
+	}
+	if len(pushers) == 0 {
+		if lastErr == nil {
+			lastErr = errors.Errorf("no registry host configured to push %s", ref)
+		}
+		return nil, lastErr
+	}
+	return &mirrorPusher{pushers: pushers}, nil
+}
+
+// mirrorFetcher retries Fetch against the next candidate host's Fetcher on
+// a 401/404/5xx response, which is where pull-through-mirror fallback
+// actually has to happen: building a docker.Resolver Fetcher never touches
+// the network, only Fetch itself does.
+type mirrorFetcher struct {
+	fetchers []remotes.Fetcher
+}
+
+func (m *mirrorFetcher) Fetch(ctx context.Context, desc ocispec.Descriptor) (io.ReadCloser, error) {
+	var lastErr error
+	for _, f := range m.fetchers {
+		rc, err := f.Fetch(ctx, desc)
+		if err == nil {
+			return rc, nil
+		}
+		lastErr = err
+		if !isFallbackErr(err) {
+			return nil, err
+		}
+	}
+	if lastErr == nil {
+		lastErr = errors.Errorf("no registry host configured to fetch %s", desc.Digest)
+	}
+	return nil, lastErr
+}
+
+// mirrorPusher is mirrorFetcher's Push-side equivalent.
+type mirrorPusher struct {
+	pushers []remotes.Pusher
+}
+
+func (m *mirrorPusher) Push(ctx context.Context, desc ocispec.Descriptor) (content.Writer, error) {
+	var lastErr error
+	for _, p := range m.pushers {
+		w, err := p.Push(ctx, desc)
+		if err == nil {
+			return w, nil
+		}
+		lastErr = err
+		if !isFallbackErr(err) {
+			return nil, err
+		}
+	}
+	if lastErr == nil {
+		lastErr = errors.Errorf("no registry host configured to push %s", desc.Digest)
+	}
+	return nil, lastErr
+}